@@ -2,14 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,6 +26,10 @@ import (
 
 const version = "0.2.0-ffprobe"
 
+// waveformSampleRate is the fixed PCM sample rate used when decoding audio
+// for peak extraction, independent of the file's native sample rate.
+const waveformSampleRate = 48000
+
 // AudioInfo represents metadata about an audio file
 type AudioInfo struct {
 	FilePath         string            `json:"file_path"`
@@ -34,6 +45,31 @@ type AudioInfo struct {
 	HasVideo         bool              `json:"has_video"`
 	Metadata         map[string]string `json:"metadata"`
 	ProcessingTimeMs int64             `json:"processing_time_ms"`
+	Peaks            [][]int16         `json:"peaks,omitempty"`
+	CoverArt         *CoverArtInfo     `json:"cover_art,omitempty"`
+
+	IntegratedLUFS        *float64 `json:"integrated_lufs,omitempty"`
+	LoudnessRangeLU       *float64 `json:"loudness_range_lu,omitempty"`
+	TruePeakDBFS          *float64 `json:"true_peak_dbfs,omitempty"`
+	LoudnessThresholdLUFS *float64 `json:"loudness_threshold_lufs,omitempty"`
+}
+
+// CoverArtInfo describes an embedded picture stream (e.g. ID3 APIC, FLAC
+// METADATA_BLOCK_PICTURE) detected alongside the audio. SizeBytes is
+// measured from the probed stream's packet, i.e. the size of the embedded
+// image data itself; it stays 0 if ffprobe cannot report that size. When
+// --extract-art writes the image out it is overwritten with the written
+// file's size, which under --art-format is the size of the transcoded file.
+type CoverArtInfo struct {
+	MimeType  string `json:"mime_type"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	SizeBytes int64  `json:"size_bytes"`
+	// StreamIndex is the ffprobe stream index this metadata was read from. It
+	// is not part of the public output, but is kept (and cached) so
+	// --extract-art can pin ffmpeg to the exact same stream via -map, even
+	// for containers carrying more than one attached picture.
+	StreamIndex int `json:"-"`
 }
 
 // FFProbeOutput represents the JSON output from ffprobe
@@ -55,12 +91,21 @@ type FFProbeFormat struct {
 
 // FFProbeStream represents stream information from ffprobe
 type FFProbeStream struct {
-	CodecName     string `json:"codec_name"`
-	CodecLongName string `json:"codec_long_name"`
-	CodecType     string `json:"codec_type"`
-	SampleRate    string `json:"sample_rate"`
-	Channels      int    `json:"channels"`
-	BitRate       string `json:"bit_rate"`
+	Index         int                `json:"index"`
+	CodecName     string             `json:"codec_name"`
+	CodecLongName string             `json:"codec_long_name"`
+	CodecType     string             `json:"codec_type"`
+	SampleRate    string             `json:"sample_rate"`
+	Channels      int                `json:"channels"`
+	BitRate       string             `json:"bit_rate"`
+	Width         int                `json:"width"`
+	Height        int                `json:"height"`
+	Disposition   FFProbeDisposition `json:"disposition"`
+}
+
+// FFProbeDisposition represents the disposition flags of a stream
+type FFProbeDisposition struct {
+	AttachedPic int `json:"attached_pic"`
 }
 
 // Result represents the processing result for a file
@@ -76,32 +121,82 @@ var (
 	recursive    bool
 	showVersion  bool
 	quiet        bool
+	waveformBins int
+	extractArt   string
+	artFormat    string
+	filesFrom    string
+	cachePath    string
+	noCache      bool
+	refreshCache bool
+	cacheStats   bool
+
+	loudnessMeasure bool
+	loudnessJobs    int
+	// loudnessSemaphore gates concurrent ebur128 passes separately from the
+	// main ffprobe concurrency, since loudness measurement is far more
+	// expensive per file. Initialized in main when --loudness is set.
+	loudnessSemaphore chan struct{}
+
+	reportMode bool
+
+	// progressOut is where the banner/progress lines are written. It defaults
+	// to stdout but is switched to stderr in streaming modes (ndjson output,
+	// --files-from) so stdout stays safe to pipe into jq or similar.
+	progressOut io.Writer = os.Stdout
+
+	// cache holds the loaded ffprobe result cache, or nil when caching is
+	// disabled (--no-cache or unable to determine the ffprobe version).
+	cache          *probeCache
+	ffprobeVersion string
 )
 
+// reportTopN is how many artists/albums are kept in the --report top-N lists.
+const reportTopN = 10
+
 func main() {
 	// Parse command line flags
 	flag.IntVar(&concurrency, "j", runtime.NumCPU()*2, "並行処理数")
-	flag.StringVar(&outputFormat, "format", "text", "出力形式 (text/json)")
+	flag.StringVar(&outputFormat, "format", "text", "出力形式 (text/json/ndjson)")
 	jsonFlag := flag.Bool("json", false, "JSON形式で出力 (--format jsonのショートカット)")
 	flag.StringVar(&outputFile, "o", "", "出力ファイルパス")
 	flag.BoolVar(&recursive, "r", false, "ディレクトリを再帰的に検索")
 	flag.BoolVar(&showVersion, "version", false, "バージョン情報を表示")
 	flag.BoolVar(&quiet, "q", false, "プログレス表示を無効化")
+	flag.IntVar(&waveformBins, "waveform", 0, "波形プレビュー用のピーク値をN分割で抽出 (0で無効)")
+	flag.StringVar(&extractArt, "extract-art", "", "埋め込みジャケット画像をこのディレクトリにエクスポート")
+	flag.StringVar(&artFormat, "art-format", "", "エクスポート時に画像をトランスコード (png/jpg)")
+	flag.StringVar(&filesFrom, "files-from", "", "ファイルリストを標準入力(-)またはファイルから読み込む")
+	flag.StringVar(&cachePath, "cache", defaultCachePath(), "ffprobe結果キャッシュのパス")
+	flag.BoolVar(&noCache, "no-cache", false, "キャッシュを使用しない")
+	flag.BoolVar(&refreshCache, "refresh", false, "キャッシュを無視して再プローブする")
+	flag.BoolVar(&cacheStats, "cache-stats", false, "キャッシュのヒット/ミス数と合計サイズを表示して終了")
+	flag.BoolVar(&loudnessMeasure, "loudness", false, "ffmpegのebur128フィルタでラウドネス(EBU R128)を測定")
+	flag.IntVar(&loudnessJobs, "loudness-jobs", runtime.NumCPU(), "ラウドネス測定の並行数 (ebur128は高コストなため別枠で制限)")
+	flag.BoolVar(&reportMode, "report", false, "コーデック/タグ/重複候補などライブラリ全体のサマリーレポートを出力")
 	flag.Parse()
 
+	if artFormat != "" && artFormat != "png" && artFormat != "jpg" {
+		log.Fatalf("無効な --art-format: %s (png/jpgのいずれかを指定してください)", artFormat)
+	}
+
 	if showVersion {
 		fmt.Printf("Audio Probe Go FFprobe v%s\n", version)
 		os.Exit(0)
 	}
 
+	if cacheStats {
+		printCacheStats(cachePath)
+		os.Exit(0)
+	}
+
 	if *jsonFlag {
 		outputFormat = "json"
 	}
 
-	args := flag.Args()
-	if len(args) == 0 {
-		printUsage()
-		os.Exit(1)
+	// ndjsonやfiles-fromのストリーミングモードではstdoutを結果専用に空けるため
+	// バナー/プログレス表示をstderrに切り替える
+	if outputFormat == "ndjson" || filesFrom != "" {
+		progressOut = os.Stderr
 	}
 
 	// Check if ffprobe is available
@@ -109,10 +204,40 @@ func main() {
 		log.Fatal("ffprobeが見つかりません。FFmpegをインストールしてください。")
 	}
 
-	// Collect audio files
-	audioFiles, err := collectAudioFiles(args, recursive)
-	if err != nil {
-		log.Fatalf("ファイル収集エラー: %v", err)
+	if !noCache {
+		ver, verErr := ffprobeVersionString()
+		if verErr != nil {
+			log.Printf("ffprobeバージョン取得エラー: %v (キャッシュを無効化します)", verErr)
+		} else {
+			ffprobeVersion = ver
+			loaded, loadErr := loadProbeCache(cachePath)
+			if loadErr != nil {
+				log.Printf("キャッシュ読み込みエラー: %v (キャッシュを無効化します)", loadErr)
+			} else {
+				cache = loaded
+			}
+		}
+	}
+
+	var audioFiles []string
+	var err error
+
+	if filesFrom != "" {
+		audioFiles, err = readFilesFrom(filesFrom)
+		if err != nil {
+			log.Fatalf("ファイルリスト読み込みエラー: %v", err)
+		}
+	} else {
+		args := flag.Args()
+		if len(args) == 0 {
+			printUsage()
+			os.Exit(1)
+		}
+
+		audioFiles, err = collectAudioFiles(args, recursive)
+		if err != nil {
+			log.Fatalf("ファイル収集エラー: %v", err)
+		}
 	}
 
 	if len(audioFiles) == 0 {
@@ -120,8 +245,39 @@ func main() {
 		os.Exit(0)
 	}
 
+	if loudnessMeasure {
+		loudnessSemaphore = make(chan struct{}, loudnessJobs)
+	}
+
+	if reportMode && outputFormat == "ndjson" {
+		log.Println("警告: --format ndjsonでは結果をバッファしないため--reportは無視されます")
+		reportMode = false
+	}
+
+	if outputFormat == "ndjson" {
+		var out *os.File
+		if outputFile != "" {
+			out, err = os.Create(outputFile)
+			if err != nil {
+				log.Fatalf("出力ファイルの作成に失敗: %v", err)
+			}
+			defer out.Close()
+		} else {
+			out = os.Stdout
+		}
+
+		writer := bufio.NewWriter(out)
+		processFilesStreaming(audioFiles, concurrency, writer)
+		if err := writer.Flush(); err != nil {
+			log.Fatalf("出力エラー: %v", err)
+		}
+		saveProbeCache()
+		return
+	}
+
 	// Process files
 	results := processFiles(audioFiles, concurrency)
+	saveProbeCache()
 
 	// Output results
 	if err := outputResults(results, outputFormat, outputFile); err != nil {
@@ -129,6 +285,237 @@ func main() {
 	}
 }
 
+// readFilesFrom reads a newline-separated list of file paths from stdin
+// (source == "-") or from the given file, skipping blank lines.
+func readFilesFrom(source string) ([]string, error) {
+	var r io.Reader
+	if source == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("ファイルリストを開けません: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ファイルリストの読み込みに失敗: %v", err)
+	}
+
+	return files, nil
+}
+
+// probeCache persists ffprobe results keyed by (path, size, mtime, ffprobe
+// version) so rescanning a library whose files haven't changed skips the
+// ffprobe subprocess spawn entirely.
+type probeCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]cacheEntry
+	hits    int64
+	misses  int64
+}
+
+// cacheEntry is one cached probe result, along with the file stat and
+// ffprobe version it was computed against.
+type cacheEntry struct {
+	Size           int64
+	ModTime        int64
+	FFProbeVersion string
+	Info           AudioInfo
+}
+
+// cacheFile is the on-disk gob encoding of a probeCache.
+type cacheFile struct {
+	Entries map[string]cacheEntry
+	Hits    int64
+	Misses  int64
+}
+
+// defaultCachePath returns $XDG_CACHE_HOME/audio-probe-go-ffmpeg/cache.db
+// (or the platform equivalent via os.UserCacheDir).
+func defaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "audio-probe-go-ffmpeg", "cache.db")
+}
+
+// ffprobeVersionString returns the first line of `ffprobe -version`, used as
+// part of the cache key so a ffprobe upgrade invalidates old entries.
+func ffprobeVersionString() (string, error) {
+	output, err := exec.Command("ffprobe", "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobeバージョン取得エラー: %v", err)
+	}
+	firstLine := strings.SplitN(string(output), "\n", 2)[0]
+	return strings.TrimSpace(firstLine), nil
+}
+
+func loadProbeCache(path string) (*probeCache, error) {
+	c := &probeCache{path: path, entries: make(map[string]cacheEntry)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var cf cacheFile
+	if err := gob.NewDecoder(f).Decode(&cf); err != nil {
+		return nil, fmt.Errorf("キャッシュのデコードに失敗: %v", err)
+	}
+
+	if cf.Entries != nil {
+		c.entries = cf.Entries
+	}
+	c.hits = cf.Hits
+	c.misses = cf.Misses
+
+	return c, nil
+}
+
+// save writes the cache back to disk atomically via a temp file + rename.
+func (c *probeCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("キャッシュディレクトリの作成に失敗: %v", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("キャッシュ一時ファイルの作成に失敗: %v", err)
+	}
+
+	cf := cacheFile{Entries: c.entries, Hits: c.hits, Misses: c.misses}
+	if err := gob.NewEncoder(f).Encode(cf); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("キャッシュのエンコードに失敗: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, c.path)
+}
+
+func cacheKeyFor(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filePath
+}
+
+// cloneAudioInfo deep-copies the mutable parts of info (Metadata map,
+// CoverArt pointer) so cache entries and their callers never alias memory.
+// Peaks is always dropped: waveform extraction is a separate, flag-gated
+// ffmpeg pass that is never persisted in the probe cache.
+func cloneAudioInfo(info *AudioInfo) AudioInfo {
+	clone := *info
+
+	if info.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(info.Metadata))
+		for k, v := range info.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+	if info.CoverArt != nil {
+		coverArt := *info.CoverArt
+		clone.CoverArt = &coverArt
+	}
+	clone.Peaks = nil
+
+	return clone
+}
+
+func (c *probeCache) lookup(filePath string, fi os.FileInfo, ffprobeVer string) (*AudioInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[cacheKeyFor(filePath)]
+	if !ok || entry.Size != fi.Size() || entry.ModTime != fi.ModTime().UnixNano() || entry.FFProbeVersion != ffprobeVer {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+
+	info := cloneAudioInfo(&entry.Info)
+	return &info, true
+}
+
+func (c *probeCache) store(filePath string, fi os.FileInfo, ffprobeVer string, info *AudioInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[cacheKeyFor(filePath)] = cacheEntry{
+		Size:           fi.Size(),
+		ModTime:        fi.ModTime().UnixNano(),
+		FFProbeVersion: ffprobeVer,
+		Info:           cloneAudioInfo(info),
+	}
+}
+
+// stats returns cumulative hit/miss counts and the on-disk cache size.
+// sizeBytes is -1 if the cache file doesn't exist yet.
+func (c *probeCache) stats() (hits, misses int64, entries int, sizeBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sizeBytes = -1
+	if fi, err := os.Stat(c.path); err == nil {
+		sizeBytes = fi.Size()
+	}
+
+	return c.hits, c.misses, len(c.entries), sizeBytes
+}
+
+// printCacheStats implements the --cache-stats mode: load the cache
+// read-only and report its cumulative hit/miss counts and size.
+func printCacheStats(path string) {
+	c, err := loadProbeCache(path)
+	if err != nil {
+		log.Fatalf("キャッシュ読み込みエラー: %v", err)
+	}
+
+	hits, misses, entries, sizeBytes := c.stats()
+	fmt.Printf("キャッシュパス: %s\n", path)
+	fmt.Printf("エントリ数: %d\n", entries)
+	if sizeBytes >= 0 {
+		fmt.Printf("合計サイズ: %s\n", formatBytes(sizeBytes))
+	} else {
+		fmt.Println("合計サイズ: キャッシュファイルが存在しません")
+	}
+	fmt.Printf("累計ヒット: %d, 累計ミス: %d\n", hits, misses)
+}
+
+// saveProbeCache flushes the global cache to disk if caching is enabled.
+func saveProbeCache() {
+	if cache == nil {
+		return
+	}
+	if err := cache.save(); err != nil {
+		log.Printf("キャッシュ保存エラー: %v", err)
+	}
+}
+
 func checkFFProbe() bool {
 	cmd := exec.Command("ffprobe", "-version")
 	err := cmd.Run()
@@ -143,6 +530,10 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "  %s audio.mp3\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s -j 100 /path/to/music/\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s --json -r /path/to/music/ > results.json\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  find /path/to/music -name '*.flac' | %s --files-from - --format ndjson | jq .\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --cache-stats\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --loudness --loudness-jobs 2 -r /path/to/music/\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --report -r /path/to/music/\n", os.Args[0])
 }
 
 func collectAudioFiles(paths []string, recursive bool) ([]string, error) {
@@ -214,37 +605,37 @@ func processFiles(files []string, maxConcurrency int) []Result {
 		maxConcurrency = cpuLimit
 	}
 
-	fmt.Printf("🎵 Audio Probe Go FFprobe - 高性能音声ファイル解析ツール (v%s)\n", version)
-	fmt.Println("FFprobeを使用して実際の音声ファイル情報を解析します")
+	fmt.Fprintf(progressOut, "🎵 Audio Probe Go FFprobe - 高性能音声ファイル解析ツール (v%s)\n", version)
+	fmt.Fprintln(progressOut, "FFprobeを使用して実際の音声ファイル情報を解析します")
 	log.Printf("Found %d audio files to process", len(files))
 	log.Printf("Processing %d files with max %d concurrent operations", len(files), maxConcurrency)
 
 	startTime := time.Now()
 	results := make([]Result, len(files))
-	
+
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, maxConcurrency)
 	var processed int32
-	
+
 	// プログレス表示
 	var progressWg sync.WaitGroup
 	progressDone := make(chan bool)
-	
+
 	if !quiet {
 		progressWg.Add(1)
 		go func() {
 			defer progressWg.Done()
 			ticker := time.NewTicker(100 * time.Millisecond)
 			defer ticker.Stop()
-			
+
 			for {
 				select {
 				case <-ticker.C:
 					p := atomic.LoadInt32(&processed)
 					progress := float64(p) / float64(len(files)) * 100
-					fmt.Printf("\r  [%.0f%%] %d/%d files processed", progress, p, len(files))
+					fmt.Fprintf(progressOut, "\r  [%.0f%%] %d/%d files processed", progress, p, len(files))
 				case <-progressDone:
-					fmt.Printf("\r  [100%%] %d/%d files processed ✓      \n", len(files), len(files))
+					fmt.Fprintf(progressOut, "\r  [100%%] %d/%d files processed ✓      \n", len(files), len(files))
 					return
 				}
 			}
@@ -290,6 +681,83 @@ func processFiles(files []string, maxConcurrency int) []Result {
 	return results
 }
 
+// processFilesStreaming analyzes files with the same concurrency model as
+// processFiles, but writes each AudioInfo as an NDJSON line to w as soon as
+// its goroutine completes, instead of buffering every result into a slice
+// first. This is what makes --format ndjson usable on very large libraries.
+func processFilesStreaming(files []string, maxConcurrency int, w io.Writer) (successCount, failCount int) {
+	cpuLimit := runtime.NumCPU() * 12
+	if maxConcurrency > cpuLimit {
+		log.Printf("警告: 並行数 %d はCPUコア数の12倍 (%d) を超えています。調整します。", maxConcurrency, cpuLimit)
+		maxConcurrency = cpuLimit
+	}
+
+	fmt.Fprintf(progressOut, "🎵 Audio Probe Go FFprobe - 高性能音声ファイル解析ツール (v%s)\n", version)
+	fmt.Fprintln(progressOut, "FFprobeを使用して実際の音声ファイル情報を解析します")
+	log.Printf("Found %d audio files to process", len(files))
+	log.Printf("Processing %d files with max %d concurrent operations (streaming)", len(files), maxConcurrency)
+
+	startTime := time.Now()
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrency)
+	resultsCh := make(chan Result, maxConcurrency)
+
+	for _, file := range files {
+		wg.Add(1)
+		go func(filePath string) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			info, err := analyzeFileWithFFProbe(filePath)
+			resultsCh <- Result{Info: info, Error: err}
+		}(file)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+	}()
+
+	encoder := json.NewEncoder(w)
+	var processed int
+
+	for result := range resultsCh {
+		processed++
+		if !quiet {
+			fmt.Fprintf(progressOut, "\r  %d/%d files processed", processed, len(files))
+		}
+
+		if result.Error != nil {
+			failCount++
+			fmt.Fprintf(progressOut, "\nエラー: %v\n", result.Error)
+			continue
+		}
+
+		if err := encoder.Encode(result.Info); err != nil {
+			log.Printf("NDJSON書き込みエラー: %v", err)
+			failCount++
+			continue
+		}
+		successCount++
+	}
+
+	if !quiet {
+		fmt.Fprintf(progressOut, "\r  [100%%] %d/%d files processed ✓      \n", len(files), len(files))
+	}
+
+	elapsed := time.Since(startTime)
+	log.Printf("Processing completed in %.2fs", elapsed.Seconds())
+	log.Printf("Successfully processed: %d", successCount)
+	if failCount > 0 {
+		log.Printf("Failed: %d", failCount)
+	}
+
+	return successCount, failCount
+}
+
 func analyzeFileWithFFProbe(filePath string) (*AudioInfo, error) {
 	startTime := time.Now()
 
@@ -299,6 +767,81 @@ func analyzeFileWithFFProbe(filePath string) (*AudioInfo, error) {
 		return nil, fmt.Errorf("ファイルが見つかりません: %v", err)
 	}
 
+	var info *AudioInfo
+	if cache != nil && !refreshCache {
+		if cached, ok := cache.lookup(filePath, fileInfo, ffprobeVersion); ok {
+			info = cached
+		}
+	}
+
+	if info == nil {
+		info, err = probeFile(filePath, fileInfo)
+		if err != nil {
+			return nil, err
+		}
+		if cache != nil {
+			cache.store(filePath, fileInfo, ffprobeVersion, info)
+		}
+	}
+
+	if info.CoverArt != nil && extractArt != "" {
+		written, size, err := extractCoverArt(filePath, extractArt, artFormat, info.CoverArt.MimeType, info.CoverArt.StreamIndex)
+		if err != nil {
+			log.Printf("ジャケット画像抽出エラー (%s): %v", filePath, err)
+		} else {
+			info.CoverArt.SizeBytes = size
+			log.Printf("ジャケット画像を書き出しました: %s", written)
+		}
+	}
+
+	if waveformBins > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		peaks, err := extractPeaks(ctx, filePath, info.Channels, info.DurationSeconds, waveformBins)
+		cancel()
+		if err != nil {
+			log.Printf("波形抽出エラー (%s): %v", filePath, err)
+		} else {
+			info.Peaks = peaks
+		}
+	}
+
+	if loudnessMeasure {
+		if info.DurationSeconds < 3 {
+			log.Printf("警告: %s は再生時間が%.1f秒でEBU R128の測定には短すぎます(3秒以上を推奨)", filePath, info.DurationSeconds)
+		}
+
+		if loudnessSemaphore != nil {
+			loudnessSemaphore <- struct{}{}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		loudness, err := measureLoudness(ctx, filePath)
+		cancel()
+
+		if loudnessSemaphore != nil {
+			<-loudnessSemaphore
+		}
+
+		if err != nil {
+			log.Printf("ラウドネス測定エラー (%s): %v", filePath, err)
+		} else {
+			info.IntegratedLUFS = loudness.Integrated
+			info.LoudnessRangeLU = loudness.Range
+			info.TruePeakDBFS = loudness.TruePeak
+			info.LoudnessThresholdLUFS = loudness.Threshold
+		}
+	}
+
+	info.ProcessingTimeMs = time.Since(startTime).Milliseconds()
+
+	return info, nil
+}
+
+// probeFile runs ffprobe against filePath and builds the base AudioInfo
+// (everything except the ffmpeg-only Peaks and loudness fields and
+// ProcessingTimeMs, which are filled in by the caller after this result is
+// possibly cached).
+func probeFile(filePath string, fileInfo os.FileInfo) (*AudioInfo, error) {
 	// ffprobeコマンドを実行
 	cmd := exec.Command("ffprobe",
 		"-v", "quiet",
@@ -320,13 +863,19 @@ func analyzeFileWithFFProbe(filePath string) (*AudioInfo, error) {
 
 	// 音声ストリームを探す
 	var audioStream *FFProbeStream
+	var coverStream *FFProbeStream
 	hasVideo := false
 
-	for _, stream := range probeData.Streams {
+	for i := range probeData.Streams {
+		stream := &probeData.Streams[i]
 		if stream.CodecType == "audio" && audioStream == nil {
-			audioStream = &stream
+			audioStream = stream
 		} else if stream.CodecType == "video" {
-			hasVideo = true
+			if stream.Disposition.AttachedPic == 1 {
+				coverStream = stream
+			} else {
+				hasVideo = true
+			}
 		}
 	}
 
@@ -353,19 +902,18 @@ func analyzeFileWithFFProbe(filePath string) (*AudioInfo, error) {
 
 	// AudioInfo構築
 	info := &AudioInfo{
-		FilePath:         filePath,
-		FileSize:         fileInfo.Size(),
-		DurationSeconds:  duration,
-		BitRate:          bitRate,
-		SampleRate:       sampleRate,
-		Channels:         audioStream.Channels,
-		CodecName:        audioStream.CodecName,
-		CodecLongName:    audioStream.CodecLongName,
-		FormatName:       probeData.Format.FormatName,
-		FormatLongName:   probeData.Format.FormatLongName,
-		HasVideo:         hasVideo,
-		Metadata:         metadata,
-		ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+		FilePath:        filePath,
+		FileSize:        fileInfo.Size(),
+		DurationSeconds: duration,
+		BitRate:         bitRate,
+		SampleRate:      sampleRate,
+		Channels:        audioStream.Channels,
+		CodecName:       audioStream.CodecName,
+		CodecLongName:   audioStream.CodecLongName,
+		FormatName:      probeData.Format.FormatName,
+		FormatLongName:  probeData.Format.FormatLongName,
+		HasVideo:        hasVideo,
+		Metadata:        metadata,
 	}
 
 	// メタデータが空の場合、デフォルト値を設定
@@ -380,9 +928,347 @@ func analyzeFileWithFFProbe(filePath string) (*AudioInfo, error) {
 		info.Metadata["album"] = "Unknown Album"
 	}
 
+	if coverStream != nil {
+		info.CoverArt = &CoverArtInfo{
+			MimeType:    coverArtMimeType(coverStream.CodecName),
+			Width:       coverStream.Width,
+			Height:      coverStream.Height,
+			StreamIndex: coverStream.Index,
+		}
+		if size, err := coverArtPacketSize(filePath, coverStream.Index); err != nil {
+			log.Printf("ジャケット画像サイズ取得エラー (%s): %v", filePath, err)
+		} else {
+			info.CoverArt.SizeBytes = size
+		}
+	}
+
 	return info, nil
 }
 
+// coverArtPacketSize returns the byte size of the embedded picture stream at
+// streamIndex by reading its (single) packet size directly via ffprobe, so
+// CoverArtInfo.SizeBytes can be populated without writing the image to disk.
+func coverArtPacketSize(filePath string, streamIndex int) (int64, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-select_streams", strconv.Itoa(streamIndex),
+		"-show_entries", "packet=size",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filePath)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe実行エラー: %v", err)
+	}
+
+	line := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]
+	if line == "" {
+		return 0, fmt.Errorf("パケットサイズが取得できません")
+	}
+
+	size, err := strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("パケットサイズの解析に失敗しました: %v", err)
+	}
+
+	return size, nil
+}
+
+// extractPeaks decodes filePath to raw s16le PCM via ffmpeg and reduces it to
+// numBins per-channel peaks suitable for rendering a waveform preview.
+func extractPeaks(ctx context.Context, filePath string, channels int, duration float64, numBins int) ([][]int16, error) {
+	if channels <= 0 {
+		channels = 1
+	}
+	if duration <= 0 || numBins <= 0 {
+		return nil, fmt.Errorf("波形抽出には再生時間とビン数が必要です")
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "quiet",
+		"-i", filePath,
+		"-f", "s16le",
+		"-acodec", "pcm_s16le",
+		"-ar", strconv.Itoa(waveformSampleRate),
+		"-ac", strconv.Itoa(channels),
+		"pipe:1")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpegパイプ取得エラー: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg起動エラー: %v", err)
+	}
+
+	frames := int64(duration * float64(waveformSampleRate))
+	framesPerBin := (frames + int64(numBins) - 1) / int64(numBins)
+	if framesPerBin < 1 {
+		framesPerBin = 1
+	}
+
+	peaks := make([][]int16, channels)
+	for c := range peaks {
+		peaks[c] = make([]int16, 0, numBins)
+	}
+
+	binMax := make([]int16, channels)
+	var frameInBin int64
+
+	// flushBin caps each channel at numBins: duration comes from ffprobe's
+	// format-level estimate, which can undercount the true decoded frame
+	// count (VBR or slightly-off container durations), so the real PCM
+	// stream can run past framesPerBin*numBins. Any trailing frames beyond
+	// numBins are discarded here instead of growing peaks past the
+	// fixed-size array callers (sparkline, JSON) expect.
+	flushBin := func() {
+		for c := 0; c < channels; c++ {
+			if len(peaks[c]) < numBins {
+				peaks[c] = append(peaks[c], binMax[c])
+			}
+			binMax[c] = 0
+		}
+		frameInBin = 0
+	}
+
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	sample := make([]byte, 2)
+
+readLoop:
+	for {
+		for c := 0; c < channels; c++ {
+			// io.ReadFull reassembles a 2-byte sample even when it straddles
+			// a bufio read boundary, so partial reads never corrupt a bin.
+			if _, err := io.ReadFull(reader, sample); err != nil {
+				break readLoop
+			}
+			// int16's negation overflows at math.MinInt16 (-32768, a
+			// legitimate full-scale sample), so widen before negating.
+			abs := int32(int16(binary.LittleEndian.Uint16(sample)))
+			if abs < 0 {
+				abs = -abs
+			}
+			if abs > math.MaxInt16 {
+				abs = math.MaxInt16
+			}
+			if int16(abs) > binMax[c] {
+				binMax[c] = int16(abs)
+			}
+		}
+		frameInBin++
+		if frameInBin >= framesPerBin {
+			flushBin()
+		}
+	}
+
+	if frameInBin > 0 {
+		flushBin()
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg実行エラー: %v", err)
+	}
+
+	return peaks, nil
+}
+
+// loudnessResult holds the EBU R128 summary values parsed from ffmpeg's
+// ebur128 filter stderr output. Fields are pointers so a line ffmpeg never
+// emitted stays distinguishable from a genuine 0 reading.
+type loudnessResult struct {
+	Integrated *float64
+	Threshold  *float64
+	Range      *float64
+	TruePeak   *float64
+}
+
+var (
+	reIntegratedLoudness = regexp.MustCompile(`^\s*I:\s*(-?[\d.]+) LUFS`)
+	reLoudnessThreshold  = regexp.MustCompile(`^\s*Threshold:\s*(-?[\d.]+) LUFS`)
+	reLoudnessRange      = regexp.MustCompile(`^\s*LRA:\s*(-?[\d.]+) LU`)
+	reTruePeak           = regexp.MustCompile(`^\s*Peak:\s*(-?[\d.]+) dBFS`)
+)
+
+// measureLoudness runs a second ffmpeg pass with the ebur128 filter and
+// parses its stderr summary (I:, Threshold:, LRA:, Peak:) into a
+// loudnessResult. Only the first Threshold: line is kept, since it belongs
+// to the "Integrated loudness" block; the "Loudness range" block repeats
+// the label for its own gating threshold.
+func measureLoudness(ctx context.Context, filePath string) (*loudnessResult, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", filePath,
+		"-af", "ebur128=peak=true",
+		"-f", "null",
+		"-")
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpegパイプ取得エラー: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg起動エラー: %v", err)
+	}
+
+	result := &loudnessResult{}
+	thresholdCaptured := false
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case reIntegratedLoudness.MatchString(line):
+			if v, ok := parseLoudnessValue(reIntegratedLoudness, line); ok {
+				result.Integrated = &v
+			}
+		case !thresholdCaptured && reLoudnessThreshold.MatchString(line):
+			if v, ok := parseLoudnessValue(reLoudnessThreshold, line); ok {
+				result.Threshold = &v
+				thresholdCaptured = true
+			}
+		case reLoudnessRange.MatchString(line):
+			if v, ok := parseLoudnessValue(reLoudnessRange, line); ok {
+				result.Range = &v
+			}
+		case reTruePeak.MatchString(line):
+			if v, ok := parseLoudnessValue(reTruePeak, line); ok {
+				result.TruePeak = &v
+			}
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg実行エラー: %v", err)
+	}
+
+	return result, nil
+}
+
+// parseLoudnessValue extracts and parses the numeric capture group of re
+// from line.
+func parseLoudnessValue(re *regexp.Regexp, line string) (float64, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// coverArtMimeType maps an ffprobe image codec name to a MIME type.
+func coverArtMimeType(codecName string) string {
+	switch codecName {
+	case "mjpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "bmp":
+		return "image/bmp"
+	case "gif":
+		return "image/gif"
+	default:
+		return "image/" + codecName
+	}
+}
+
+// extractCoverArt writes the embedded picture stream at streamIndex of
+// filePath into outDir. If format is empty the image is copied without
+// transcoding, keeping its native codec (derived from mimeType); otherwise
+// ffmpeg transcodes it to png or jpg. streamIndex is mapped explicitly so
+// containers with more than one attached picture (e.g. FLAC front+back
+// cover) always export the stream that CoverArtInfo describes.
+func extractCoverArt(filePath, outDir, format, mimeType string, streamIndex int) (string, int64, error) {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("出力ディレクトリの作成に失敗: %v", err)
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	mapSpec := "0:" + strconv.Itoa(streamIndex)
+
+	var args []string
+	var outPath string
+
+	if format == "" {
+		ext := coverArtExtension(mimeType)
+		outPath = filepath.Join(outDir, baseName+"."+ext)
+		args = []string{"-i", filePath, "-map", mapSpec, "-an", "-codec:v", "copy", "-f", "image2", "-y", outPath}
+	} else {
+		outPath = filepath.Join(outDir, baseName+"."+format)
+		args = []string{"-i", filePath, "-map", mapSpec, "-an", "-f", "image2", "-y", outPath}
+	}
+
+	cmd := exec.Command("ffmpeg", append([]string{"-v", "quiet"}, args...)...)
+	if err := cmd.Run(); err != nil {
+		return "", 0, fmt.Errorf("ffmpeg実行エラー: %v", err)
+	}
+
+	written, err := os.Stat(outPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("書き出した画像の取得に失敗: %v", err)
+	}
+
+	return outPath, written.Size(), nil
+}
+
+// coverArtExtension maps a cover art MIME type (as produced by
+// coverArtMimeType) back to a file extension.
+func coverArtExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return "jpg"
+	case "image/png":
+		return "png"
+	case "image/bmp":
+		return "bmp"
+	case "image/gif":
+		return "gif"
+	default:
+		_, ext, found := strings.Cut(mimeType, "/")
+		if !found || ext == "" {
+			return "img"
+		}
+		return ext
+	}
+}
+
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders a slice of peak values as a compact ASCII/Unicode
+// sparkline scaled to the slice's own maximum.
+func sparkline(peaks []int16) string {
+	if len(peaks) == 0 {
+		return ""
+	}
+
+	var max int16
+	for _, v := range peaks {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	runes := make([]rune, len(peaks))
+	for i, v := range peaks {
+		idx := int(float64(v) / float64(max) * float64(len(sparkChars)-1))
+		if idx < 0 {
+			idx = 0
+		} else if idx >= len(sparkChars) {
+			idx = len(sparkChars) - 1
+		}
+		runes[i] = sparkChars[idx]
+	}
+
+	return string(runes)
+}
+
 func outputResults(results []Result, format string, outputFile string) error {
 	var output *os.File
 	var err error
@@ -403,10 +1289,20 @@ func outputResults(results []Result, format string, outputFile string) error {
 
 	switch format {
 	case "json":
-		return outputJSON(writer, results)
+		if err := outputJSON(writer, results); err != nil {
+			return err
+		}
 	default:
-		return outputText(writer, results)
+		if err := outputText(writer, results); err != nil {
+			return err
+		}
+	}
+
+	if reportMode {
+		return outputReport(writer, results, format)
 	}
+
+	return nil
 }
 
 func outputJSON(w *bufio.Writer, results []Result) error {
@@ -470,11 +1366,358 @@ func outputText(w *bufio.Writer, results []Result) error {
 				}
 			}
 		}
+
+		if info.CoverArt != nil {
+			fmt.Fprintf(w, "   ジャケット画像: %s %dx%d", info.CoverArt.MimeType, info.CoverArt.Width, info.CoverArt.Height)
+			if info.CoverArt.SizeBytes > 0 {
+				fmt.Fprintf(w, " (%s)", formatBytes(info.CoverArt.SizeBytes))
+			}
+			fmt.Fprintln(w)
+		}
+
+		if len(info.Peaks) > 0 {
+			fmt.Fprintln(w, "   波形:")
+			for ch, peaks := range info.Peaks {
+				fmt.Fprintf(w, "     ch%d: %s\n", ch, sparkline(peaks))
+			}
+		}
+
+		if info.IntegratedLUFS != nil {
+			fmt.Fprintln(w, "   ラウドネス (EBU R128):")
+			fmt.Fprintf(w, "     統合ラウドネス: %.1f LUFS\n", *info.IntegratedLUFS)
+			if info.LoudnessRangeLU != nil {
+				fmt.Fprintf(w, "     ラウドネスレンジ: %.1f LU\n", *info.LoudnessRangeLU)
+			}
+			if info.TruePeakDBFS != nil {
+				fmt.Fprintf(w, "     トゥルーピーク: %.1f dBFS\n", *info.TruePeakDBFS)
+			}
+			if info.LoudnessThresholdLUFS != nil {
+				fmt.Fprintf(w, "     ゲート閾値: %.1f LUFS\n", *info.LoudnessThresholdLUFS)
+			}
+		}
 	}
 	
 	return nil
 }
 
+// LibraryReport is the library-wide summary produced by --report: codec,
+// sample rate, bit rate and channel histograms, top artists/albums, files missing critical tags, and
+// duplicate-audio candidates.
+type LibraryReport struct {
+	TotalFiles          int              `json:"total_files"`
+	SuccessfulFiles     int              `json:"successful_files"`
+	FailedFiles         int              `json:"failed_files"`
+	CodecHistogram      map[string]int   `json:"codec_histogram"`
+	SampleRateHistogram map[string]int   `json:"sample_rate_histogram"`
+	BitRateHistogram    map[string]int   `json:"bit_rate_histogram"`
+	ChannelHistogram    map[string]int   `json:"channel_histogram"`
+	TopArtists          []ArtistStat     `json:"top_artists"`
+	TopAlbums           []AlbumStat      `json:"top_albums"`
+	MissingCriticalTags []string         `json:"missing_critical_tags"`
+	DuplicateGroups     []DuplicateGroup `json:"duplicate_groups"`
+}
+
+// ArtistStat is one row of the top-artists table.
+type ArtistStat struct {
+	Artist            string  `json:"artist"`
+	TrackCount        int     `json:"track_count"`
+	TotalDurationSecs float64 `json:"total_duration_seconds"`
+}
+
+// AlbumStat is one row of the top-albums table.
+type AlbumStat struct {
+	Album             string  `json:"album"`
+	TrackCount        int     `json:"track_count"`
+	TotalDurationSecs float64 `json:"total_duration_seconds"`
+}
+
+// DuplicateGroup is a set of files believed to be the same recording.
+type DuplicateGroup struct {
+	Files  []string `json:"files"`
+	Reason string   `json:"reason"`
+}
+
+// dupCandidateKey groups files by the cheap, ffprobe-derived signature used
+// as stage one of duplicate detection.
+type dupCandidateKey struct {
+	durationRounded int64
+	channels        int
+	sampleRate      int
+}
+
+// outputReport builds the library report from results and writes it to w in
+// the same format as the per-file output (text tables, or a JSON object).
+func outputReport(w *bufio.Writer, results []Result, format string) error {
+	report := buildLibraryReport(results)
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	return printLibraryReportText(w, report)
+}
+
+// buildLibraryReport computes histograms, top-N tables, and duplicate
+// candidates from a completed analysis run.
+func buildLibraryReport(results []Result) *LibraryReport {
+	report := &LibraryReport{
+		TotalFiles:          len(results),
+		CodecHistogram:      make(map[string]int),
+		SampleRateHistogram: make(map[string]int),
+		BitRateHistogram:    make(map[string]int),
+		ChannelHistogram:    make(map[string]int),
+	}
+
+	artistStats := make(map[string]*ArtistStat)
+	albumStats := make(map[string]*AlbumStat)
+	candidates := make(map[dupCandidateKey][]string)
+
+	for _, result := range results {
+		if result.Error != nil || result.Info == nil {
+			report.FailedFiles++
+			continue
+		}
+		report.SuccessfulFiles++
+
+		info := result.Info
+		report.CodecHistogram[info.CodecName]++
+		report.SampleRateHistogram[strconv.Itoa(info.SampleRate)]++
+		report.BitRateHistogram[bitRateBucket(info.BitRate)]++
+		report.ChannelHistogram[strconv.Itoa(info.Channels)]++
+
+		artist := info.Metadata["artist"]
+		as, ok := artistStats[artist]
+		if !ok {
+			as = &ArtistStat{Artist: artist}
+			artistStats[artist] = as
+		}
+		as.TrackCount++
+		as.TotalDurationSecs += info.DurationSeconds
+
+		album := info.Metadata["album"]
+		al, ok := albumStats[album]
+		if !ok {
+			al = &AlbumStat{Album: album}
+			albumStats[album] = al
+		}
+		al.TrackCount++
+		al.TotalDurationSecs += info.DurationSeconds
+
+		// probeFile defaults missing tags to these sentinel values, so their
+		// presence is how we detect a tag was never set in the first place.
+		if info.Metadata["artist"] == "Unknown Artist" || info.Metadata["album"] == "Unknown Album" {
+			report.MissingCriticalTags = append(report.MissingCriticalTags, info.FilePath)
+		}
+
+		key := dupCandidateKey{
+			durationRounded: int64(info.DurationSeconds + 0.5),
+			channels:        info.Channels,
+			sampleRate:      info.SampleRate,
+		}
+		candidates[key] = append(candidates[key], info.FilePath)
+	}
+
+	report.TopArtists = topArtists(artistStats, reportTopN)
+	report.TopAlbums = topAlbums(albumStats, reportTopN)
+	report.DuplicateGroups = findDuplicateGroups(candidates)
+
+	return report
+}
+
+// bitRateBucket groups a bit rate in bps into a human-readable range.
+func bitRateBucket(bitRate int64) string {
+	switch {
+	case bitRate <= 0:
+		return "unknown"
+	case bitRate < 128000:
+		return "<128kbps"
+	case bitRate < 192000:
+		return "128-192kbps"
+	case bitRate < 256000:
+		return "192-256kbps"
+	case bitRate < 320000:
+		return "256-320kbps"
+	default:
+		return "320kbps+"
+	}
+}
+
+func topArtists(stats map[string]*ArtistStat, topN int) []ArtistStat {
+	list := make([]ArtistStat, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TrackCount != list[j].TrackCount {
+			return list[i].TrackCount > list[j].TrackCount
+		}
+		return list[i].TotalDurationSecs > list[j].TotalDurationSecs
+	})
+	if len(list) > topN {
+		list = list[:topN]
+	}
+	return list
+}
+
+func topAlbums(stats map[string]*AlbumStat, topN int) []AlbumStat {
+	list := make([]AlbumStat, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TrackCount != list[j].TrackCount {
+			return list[i].TrackCount > list[j].TrackCount
+		}
+		return list[i].TotalDurationSecs > list[j].TotalDurationSecs
+	})
+	if len(list) > topN {
+		list = list[:topN]
+	}
+	return list
+}
+
+// findDuplicateGroups runs the expensive stage-two PCM hash comparison only
+// within each cheap stage-one candidate group (same rounded duration,
+// channels, and sample rate), so most of the library never touches ffmpeg.
+func findDuplicateGroups(candidates map[dupCandidateKey][]string) []DuplicateGroup {
+	keys := make([]dupCandidateKey, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].durationRounded != keys[j].durationRounded {
+			return keys[i].durationRounded < keys[j].durationRounded
+		}
+		if keys[i].channels != keys[j].channels {
+			return keys[i].channels < keys[j].channels
+		}
+		return keys[i].sampleRate < keys[j].sampleRate
+	})
+
+	var groups []DuplicateGroup
+
+	for _, key := range keys {
+		files := candidates[key]
+		if len(files) < 2 {
+			continue
+		}
+
+		hashGroups := make(map[string][]string)
+		for _, f := range files {
+			hash, err := pcmHash(f)
+			if err != nil {
+				log.Printf("PCMハッシュ計算エラー (%s): %v", f, err)
+				continue
+			}
+			hashGroups[hash] = append(hashGroups[hash], f)
+		}
+
+		hashes := make([]string, 0, len(hashGroups))
+		for hash := range hashGroups {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+
+		for _, hash := range hashes {
+			hashed := hashGroups[hash]
+			if len(hashed) >= 2 {
+				sort.Strings(hashed)
+				groups = append(groups, DuplicateGroup{
+					Files:  hashed,
+					Reason: "再生時間/チャンネル数/サンプルレートとデコード後PCMのMD5が一致",
+				})
+			}
+		}
+	}
+
+	return groups
+}
+
+// pcmHash decodes the first audio stream of filePath and returns ffmpeg's
+// MD5 hash of the raw PCM, catching the same recording re-encoded into a
+// different codec or container.
+func pcmHash(filePath string) (string, error) {
+	cmd := exec.Command("ffmpeg",
+		"-v", "quiet",
+		"-i", filePath,
+		"-map", "0:a:0",
+		"-f", "hash",
+		"-hash", "md5",
+		"-")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg実行エラー: %v", err)
+	}
+
+	line := strings.TrimSpace(string(output))
+	return strings.TrimPrefix(line, "MD5="), nil
+}
+
+// printLibraryReportText renders a LibraryReport as the text tables shown
+// after the per-file --format text output.
+func printLibraryReportText(w *bufio.Writer, report *LibraryReport) error {
+	fmt.Fprintln(w, "\n=== ライブラリレポート ===")
+	fmt.Fprintf(w, "成功: %d, 失敗: %d\n", report.SuccessfulFiles, report.FailedFiles)
+
+	fmt.Fprintln(w, "\nコーデック別ファイル数:")
+	printHistogram(w, report.CodecHistogram)
+
+	fmt.Fprintln(w, "\nサンプルレート別ファイル数:")
+	printHistogram(w, report.SampleRateHistogram)
+
+	fmt.Fprintln(w, "\nビットレート帯域別ファイル数:")
+	printHistogram(w, report.BitRateHistogram)
+
+	fmt.Fprintln(w, "\nチャンネル数別ファイル数:")
+	printHistogram(w, report.ChannelHistogram)
+
+	fmt.Fprintf(w, "\nトップアーティスト (上位%d):\n", len(report.TopArtists))
+	for i, a := range report.TopArtists {
+		fmt.Fprintf(w, "  %d. %s - %d曲 (%s)\n", i+1, a.Artist, a.TrackCount, formatDuration(a.TotalDurationSecs))
+	}
+
+	fmt.Fprintf(w, "\nトップアルバム (上位%d):\n", len(report.TopAlbums))
+	for i, a := range report.TopAlbums {
+		fmt.Fprintf(w, "  %d. %s - %d曲 (%s)\n", i+1, a.Album, a.TrackCount, formatDuration(a.TotalDurationSecs))
+	}
+
+	if len(report.MissingCriticalTags) > 0 {
+		fmt.Fprintf(w, "\n必須タグ欠落ファイル (%d件):\n", len(report.MissingCriticalTags))
+		for _, f := range report.MissingCriticalTags {
+			fmt.Fprintf(w, "  - %s\n", f)
+		}
+	}
+
+	if len(report.DuplicateGroups) > 0 {
+		fmt.Fprintf(w, "\n重複候補 (%dグループ):\n", len(report.DuplicateGroups))
+		for i, g := range report.DuplicateGroups {
+			fmt.Fprintf(w, "  グループ%d (%s):\n", i+1, g.Reason)
+			for _, f := range g.Files {
+				fmt.Fprintf(w, "    - %s\n", f)
+			}
+		}
+	}
+
+	return nil
+}
+
+// printHistogram prints a histogram's entries sorted by key for stable
+// output across runs.
+func printHistogram(w *bufio.Writer, hist map[string]int) {
+	keys := make([]string, 0, len(hist))
+	for k := range hist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s: %d\n", k, hist[k])
+	}
+}
+
 func formatBytes(bytes int64) string {
 	const (
 		KB = 1024